@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// fileLogger writes each Line as a JSON object, one per line, to an
+// underlying writer — "-" for stdout, otherwise an opened file — so an
+// external process can tail execution progress with `tail -f`.
+type fileLogger struct {
+	mu sync.Mutex
+	w  *os.File
+}
+
+// newFileLogger opens dest ("-" for stdout, otherwise a path) for the
+// --log-stream flag.
+func newFileLogger(dest string) (*fileLogger, error) {
+	if dest == "-" {
+		return &fileLogger{w: os.Stdout}, nil
+	}
+	f, err := os.OpenFile(dest, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log-stream file: %v", err)
+	}
+	return &fileLogger{w: f}, nil
+}
+
+func (l *fileLogger) Write(line Line) {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(data)
+	l.w.Write([]byte("\n"))
+}
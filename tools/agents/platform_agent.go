@@ -9,28 +9,123 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
 
-// CommandResult represents the result of a command execution
+// CommandResult represents the result of a command execution. Output holds
+// the merged stdout+stderr view for backwards compatibility; Stdout and
+// Stderr hold the streams captured separately. Signer records the verified
+// identity that signed the rune this result came from, if any.
 type CommandResult struct {
 	Command     string    `json:"command"`
 	Success     bool      `json:"success"`
 	Output      string    `json:"output,omitempty"`
+	Stdout      string    `json:"stdout,omitempty"`
+	Stderr      string    `json:"stderr,omitempty"`
 	Error       string    `json:"error,omitempty"`
 	Duration    string    `json:"duration"`
 	Timestamp   time.Time `json:"timestamp"`
 	ExitCode    int       `json:"exit_code"`
 	Environment string    `json:"environment"`
+	Attempt     int       `json:"attempt,omitempty"`
+	Signer      string    `json:"signer,omitempty"`
+}
+
+// Command is the structured, argv-based representation of a single step.
+//
+// Mode selects how the step is invoked:
+//   - "exec": Exec is run directly with Args as its argv, no shell involved.
+//   - "shell": Shell is run as `/bin/bash -c <Shell>`, for pipelines,
+//     `||` fallback chains, quoting, and env-var expansion that argv-only
+//     invocation cannot express.
+//
+// Target, when set, overrides the rune's default target for this one
+// command, so a single rune can mix local and remote steps.
+type Command struct {
+	Mode   string       `json:"mode"`
+	Exec   string       `json:"exec,omitempty"`
+	Args   []string     `json:"args,omitempty"`
+	Shell  string       `json:"shell,omitempty"`
+	Target *Target      `json:"target,omitempty"`
+	Retry  *RetryPolicy `json:"retry,omitempty"`
+}
+
+// String renders the command the way it would be typed on a command line,
+// for logging and for CommandResult.Command.
+func (c Command) String() string {
+	if c.Mode == "shell" {
+		return c.Shell
+	}
+	if len(c.Args) == 0 {
+		return c.Exec
+	}
+	return c.Exec + " " + strings.Join(c.Args, " ")
+}
+
+// UnmarshalJSON accepts both the legacy plain-string command form
+// (parsed as whitespace-separated argv, mode "exec") and the new object
+// form: {"exec": "...", "args": [...]} or {"shell": "..."}.
+func (c *Command) UnmarshalJSON(data []byte) error {
+	var legacy string
+	if err := json.Unmarshal(data, &legacy); err == nil {
+		parts := strings.Fields(legacy)
+		if len(parts) == 0 {
+			return fmt.Errorf("empty command string")
+		}
+		c.Mode = "exec"
+		c.Exec = parts[0]
+		c.Args = parts[1:]
+		return nil
+	}
+
+	type commandAlias struct {
+		Exec   string       `json:"exec"`
+		Args   []string     `json:"args"`
+		Shell  string       `json:"shell"`
+		Target *Target      `json:"target"`
+		Retry  *RetryPolicy `json:"retry"`
+	}
+	var raw commandAlias
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("command must be a string or an {exec,args}/{shell} object: %v", err)
+	}
+
+	switch {
+	case raw.Shell != "":
+		c.Mode = "shell"
+		c.Shell = raw.Shell
+	case raw.Exec != "":
+		c.Mode = "exec"
+		c.Exec = raw.Exec
+		c.Args = raw.Args
+	default:
+		return fmt.Errorf("command object must set either \"exec\" or \"shell\"")
+	}
+	c.Target = raw.Target
+	c.Retry = raw.Retry
+	return nil
 }
 
 // RuneConfig represents a rune configuration for execution
 type RuneConfig struct {
-	Name        string   `json:"name"`
-	Description string   `json:"description"`
-	Commands    []string `json:"commands"`
-	Validation  struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Commands    []Command `json:"commands"`
+	// Target is the default runner (local, ssh, docker, kubectl) for every
+	// command in this rune; an individual Command.Target overrides it.
+	Target Target `json:"target,omitempty"`
+	// Vars seeds ${VAR} expansion for every command; overridden by
+	// --vars-file and then by CLI --set (see mergeVars).
+	Vars map[string]string `json:"vars,omitempty"`
+	// Secrets are loaded and injected into child process environments,
+	// never logged or persisted in CommandResult.
+	Secrets []SecretSpec `json:"secrets,omitempty"`
+	// Retry is the default retry policy for every command in this rune;
+	// an individual Command.Retry overrides it.
+	Retry      RetryPolicy `json:"retry,omitempty"`
+	Validation struct {
 		AllowedCommands []string `json:"allowed_commands"`
 		DeniedCommands  []string `json:"denied_commands"`
 		Timeout         int      `json:"timeout_seconds"`
@@ -40,11 +135,14 @@ type RuneConfig struct {
 
 // AgentConfig holds the agent configuration
 type AgentConfig struct {
-	LogFile       string   `json:"log_file"`
-	AllowedPaths  []string `json:"allowed_paths"`
-	DeniedCommands []string `json:"denied_commands"`
-	MaxTimeout    int      `json:"max_timeout_seconds"`
-	Environment   string   `json:"environment"`
+	LogFile      string   `json:"log_file"`
+	AllowedPaths []string `json:"allowed_paths"`
+	Policy       Policy   `json:"policy"`
+	MaxTimeout   int      `json:"max_timeout_seconds"`
+	Environment  string   `json:"environment"`
+	// RequireSignedRunes rejects any rune executed via --rune that lacks
+	// a valid detached signature from a --trusted-keys key.
+	RequireSignedRunes bool `json:"require_signed_runes"`
 }
 
 // Global configuration
@@ -61,10 +159,22 @@ func init() {
 			"/usr/sbin",
 			"/sbin",
 		},
-		DeniedCommands: []string{
-			"rm", "shutdown", ":(){", "mkfs", "dd", "format",
-			"del", "erase", "killall", "pkill", "kill -9",
-			"sudo rm", "sudo shutdown", "sudo mkfs",
+		Policy: Policy{
+			Rules: []PolicyRule{
+				{Name: "no-rm", ArgvEquals: map[int]string{0: "rm"}},
+				{Name: "no-shutdown", ArgvEquals: map[int]string{0: "shutdown"}},
+				{Name: "no-mkfs", ArgvEquals: map[int]string{0: "mkfs"}},
+				{Name: "no-dd", ArgvEquals: map[int]string{0: "dd"}},
+				{Name: "no-format", ArgvEquals: map[int]string{0: "format"}},
+				{Name: "no-del", ArgvEquals: map[int]string{0: "del"}},
+				{Name: "no-erase", ArgvEquals: map[int]string{0: "erase"}},
+				{Name: "no-killall", ArgvEquals: map[int]string{0: "killall"}},
+				{Name: "no-pkill", ArgvEquals: map[int]string{0: "pkill"}},
+				{Name: "no-kill-9", ArgvEquals: map[int]string{0: "kill", 1: "-9"}},
+				{Name: "no-sudo-rm", ArgvEquals: map[int]string{0: "sudo", 1: "rm"}},
+				{Name: "no-sudo-shutdown", ArgvEquals: map[int]string{0: "sudo", 1: "shutdown"}},
+				{Name: "no-sudo-mkfs", ArgvEquals: map[int]string{0: "sudo", 1: "mkfs"}},
+			},
 		},
 		MaxTimeout:  300, // 5 minutes
 		Environment: "production",
@@ -87,51 +197,78 @@ func init() {
 	}
 }
 
-// sanitizeCommand validates and sanitizes the command
-func sanitizeCommand(cmd string) (bool, string) {
-	// Check for denied commands
-	for _, denied := range config.DeniedCommands {
-		if strings.Contains(strings.ToLower(cmd), strings.ToLower(denied)) {
-			return false, fmt.Sprintf("Command contains denied pattern: %s", denied)
+// sanitizeCommand validates a Command's parsed argv (and resolved target,
+// caller identity) against config.Policy, rather than substring-matching
+// on the composed string, so an argument that merely contains a denied
+// word (e.g. "kubectl get configmap") is not confused with an invocation
+// of that word.
+func sanitizeCommand(cmd Command, target Target, caller string) (bool, string) {
+	switch cmd.Mode {
+	case "shell":
+		if strings.TrimSpace(cmd.Shell) == "" {
+			return false, "Empty shell command"
 		}
-	}
-
-	// Basic command structure validation
-	parts := strings.Fields(cmd)
-	if len(parts) == 0 {
-		return false, "Empty command"
-	}
-
-	// Check if command exists in allowed paths
-	command := parts[0]
-	found := false
-	for _, path := range config.AllowedPaths {
-		if _, err := os.Stat(filepath.Join(path, command)); err == nil {
-			found = true
-			break
+		// Shell mode has no real argv (pipes, quoting, `$(...)`
+		// substitution), so the policy only sees the literal
+		// whitespace-separated tokens — best-effort, not a guarantee.
+		if allowed, reason := config.Policy.Evaluate(strings.Fields(cmd.Shell), target, caller); !allowed {
+			return false, reason
+		}
+		return true, ""
+	case "exec":
+		if cmd.Exec == "" {
+			return false, "Empty command"
+		}
+		argv := append([]string{cmd.Exec}, cmd.Args...)
+		if allowed, reason := config.Policy.Evaluate(argv, target, caller); !allowed {
+			return false, reason
 		}
-	}
 
-	// Allow commands that are in PATH
-	if !found {
-		if _, err := exec.LookPath(command); err != nil {
-			return false, fmt.Sprintf("Command not found: %s", command)
+		// Check if the executable exists in an allowed path or in PATH
+		found := false
+		for _, path := range config.AllowedPaths {
+			if _, err := os.Stat(filepath.Join(path, cmd.Exec)); err == nil {
+				found = true
+				break
+			}
+		}
+		if !found {
+			if _, err := exec.LookPath(cmd.Exec); err != nil {
+				return false, fmt.Sprintf("Command not found: %s", cmd.Exec)
+			}
 		}
-	}
 
-	return true, ""
+		return true, ""
+	default:
+		return false, fmt.Sprintf("Unknown command mode: %s", cmd.Mode)
+	}
 }
 
-// executeCommand runs a single command and returns the result
-func executeCommand(cmd string, timeout int) CommandResult {
+// executeCommand runs a single command against the given target (the rune's
+// default, or the command's own Target override) and returns the result.
+// parent bounds the whole call, on top of the per-command timeout — the
+// daemon passes each job's own cancellable context here so POST
+// /v1/jobs/{id}/cancel actually interrupts an in-flight process rather than
+// letting it run to completion; the CLI passes context.Background().
+// secrets, if non-empty, are handed to the target's Runner (see the Runner
+// interface) to inject in whatever way that backend supports, and redacted
+// from both the result and every streamed line before either is returned.
+// caller identifies who submitted the command, for policy rules scoped to
+// a specific caller. logger, if non-nil, receives each output line as it
+// is produced.
+func executeCommand(parent context.Context, cmd Command, timeout int, target Target, secrets map[string]string, caller string, logger Logger) CommandResult {
 	result := CommandResult{
-		Command:   cmd,
-		Timestamp: time.Now(),
+		Command:     cmd.String(),
+		Timestamp:   time.Now(),
 		Environment: config.Environment,
 	}
 
+	if cmd.Target != nil {
+		target = *cmd.Target
+	}
+
 	// Validate command
-	if valid, reason := sanitizeCommand(cmd); !valid {
+	if valid, reason := sanitizeCommand(cmd, target, caller); !valid {
 		result.Success = false
 		result.Error = reason
 		result.Duration = "0s"
@@ -139,64 +276,107 @@ func executeCommand(cmd string, timeout int) CommandResult {
 		return result
 	}
 
-	// Parse command
-	parts := strings.Fields(cmd)
-	if len(parts) == 0 {
+	runner, err := newRunner(target)
+	if err != nil {
 		result.Success = false
-		result.Error = "Empty command"
+		result.Error = err.Error()
 		result.Duration = "0s"
 		result.ExitCode = -1
 		return result
 	}
 
-	// Create command with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	// Create command with timeout, bounded by the caller-supplied parent so
+	// an external cancellation (e.g. a job cancel request) ends it too.
+	ctx, cancel := context.WithTimeout(parent, time.Duration(timeout)*time.Second)
 	defer cancel()
+	ctx = contextWithLogger(ctx, logger)
+	ctx = contextWithSecrets(ctx, secrets)
+
+	var command *exec.Cmd
+	if cmd.Mode == "shell" {
+		command = exec.CommandContext(ctx, "/bin/bash", "-c", cmd.Shell)
+	} else {
+		command = exec.CommandContext(ctx, cmd.Exec, cmd.Args...)
+	}
+
+	// secrets is handed to the Runner rather than baked into command.Env
+	// here: only LocalRunner executes command as built, every remote
+	// backend re-executes it through ssh/docker/kubectl, which don't
+	// inherit command.Env, so each Runner decides how (or whether) to
+	// honor secrets for its own transport.
+	runResult, err := runner.RunCmd(ctx, command, secrets)
 
-	command := exec.CommandContext(ctx, parts[0], parts[1:]...)
-	
-	// Capture output
-	output, err := command.CombinedOutput()
-	
 	// Record duration
 	duration := time.Since(result.Timestamp)
 	result.Duration = duration.String()
-
+	result.Output = redactSecrets(runResult.Output, secrets)
+	result.Stdout = redactSecrets(runResult.Stdout, secrets)
+	result.Stderr = redactSecrets(runResult.Stderr, secrets)
+	result.Success = runResult.Success
+	result.ExitCode = runResult.ExitCode
 	if err != nil {
 		result.Success = false
-		result.Error = err.Error()
-		result.Output = string(output)
-		if exitError, ok := err.(*exec.ExitError); ok {
-			result.ExitCode = exitError.ExitCode()
-		} else {
-			result.ExitCode = -1
-		}
-	} else {
-		result.Success = true
-		result.Output = string(output)
-		result.ExitCode = 0
+		result.Error = redactSecrets(err.Error(), secrets)
+	} else if !runResult.Success {
+		result.Error = redactSecrets(runResult.Error, secrets)
 	}
 
 	// Log the result
-	log.Printf("Command: %s | Success: %t | Duration: %s | ExitCode: %d", 
-		cmd, result.Success, result.Duration, result.ExitCode)
+	log.Printf("Command: %s | Success: %t | Duration: %s | ExitCode: %d",
+		result.Command, result.Success, result.Duration, result.ExitCode)
 
 	return result
 }
 
-// executeRune runs a series of commands from a rune configuration
-func executeRune(runeFile string) ([]CommandResult, error) {
-	// Read rune configuration
+// executeRune reads a rune configuration from runeFile and runs it.
+// cliVars and fileVars override the rune's own vars block, in that order
+// (see mergeVars), so the same rune can be reused across environments.
+// trustedKeysDir, if set, verifies a detached <runeFile>.sig signature and
+// records the signer identity on every result; when
+// config.RequireSignedRunes is true, an unsigned or wrong-signed manifest
+// is rejected outright. caller identifies who is running the rune, for
+// policy rules scoped to a specific caller.
+func executeRune(ctx context.Context, runeFile string, cliVars, fileVars map[string]string, trustedKeysDir, caller string, logger Logger) ([]CommandResult, error) {
 	data, err := ioutil.ReadFile(runeFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read rune file: %v", err)
 	}
 
+	signer, err := verifyRuneFile(data, runeFile, trustedKeysDir)
+	if err != nil {
+		return nil, err
+	}
+
 	var runeConfig RuneConfig
 	if err := json.Unmarshal(data, &runeConfig); err != nil {
 		return nil, fmt.Errorf("failed to parse rune configuration: %v", err)
 	}
 
+	return executeRuneConfig(ctx, runeConfig, cliVars, fileVars, caller, signer, logger)
+}
+
+// executeRuneConfig runs an already-parsed rune configuration; it is the
+// shared core behind both the CLI's --rune flag and the daemon's
+// POST /v1/runes, which receives the config as a JSON request body rather
+// than a file (and so has no manifest to check a signature against —
+// signer is passed through as-is, typically empty for that path). ctx
+// bounds the whole run — the daemon passes each job's own cancellable
+// context so a cancel request can stop the rune between, or in the middle
+// of, its steps. When config.RequireSignedRunes is set, signer must be
+// non-empty: the CLI path only reaches this function after verifyRuneFile
+// has verified one, and the API path has no manifest to verify, so it is
+// rejected here rather than silently running unsigned.
+func executeRuneConfig(ctx context.Context, runeConfig RuneConfig, cliVars, fileVars map[string]string, caller, signer string, logger Logger) ([]CommandResult, error) {
+	if config.RequireSignedRunes && signer == "" {
+		return nil, fmt.Errorf("rune execution requires a verified signer but none was provided")
+	}
+
+	vars := mergeVars(runeConfig.Vars, fileVars, cliVars)
+	secrets, err := loadSecrets(runeConfig.Secrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load secrets: %v", err)
+	}
+
 	fmt.Printf("🔮 Executing Rune: %s\n", runeConfig.Name)
 	fmt.Printf("📝 Description: %s\n", runeConfig.Description)
 	fmt.Printf("⚡ Commands: %d\n\n", len(runeConfig.Commands))
@@ -207,19 +387,74 @@ func executeRune(runeFile string) ([]CommandResult, error) {
 		timeout = config.MaxTimeout
 	}
 
-	for i, cmd := range runeConfig.Commands {
-		fmt.Printf("🔄 [%d/%d] Executing: %s\n", i+1, len(runeConfig.Commands), cmd)
-		
-		result := executeCommand(cmd, timeout)
-		results = append(results, result)
+	for i, rawCmd := range runeConfig.Commands {
+		if ctx.Err() != nil {
+			break
+		}
 
-		if result.Success {
-			fmt.Printf("✅ Success: %s\n", result.Duration)
-		} else {
-			fmt.Printf("❌ Failed: %s\n", result.Error)
+		cmd, err := expandCommand(rawCmd, vars)
+		if err != nil {
+			result := CommandResult{
+				Command:     rawCmd.String(),
+				Success:     false,
+				Error:       err.Error(),
+				Duration:    "0s",
+				Timestamp:   time.Now(),
+				ExitCode:    -1,
+				Environment: config.Environment,
+				Signer:      signer,
+			}
+			results = append(results, result)
+			fmt.Printf("❌ [%d/%d] %s\n", i+1, len(runeConfig.Commands), err)
+			if runeConfig.Validation.StopOnFailure {
+				fmt.Println("🛑 Stopping execution due to failure")
+				break
+			}
+			continue
+		}
+
+		fmt.Printf("🔄 [%d/%d] Executing: %s\n", i+1, len(runeConfig.Commands), cmd.String())
+
+		policy := runeConfig.Retry
+		if cmd.Retry != nil {
+			policy = *cmd.Retry
+		}
+		policy = policy.withDefaults()
+
+		var result CommandResult
+		for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+			result = executeCommand(ctx, cmd, timeout, runeConfig.Target, secrets, caller, logger)
+			result.Attempt = attempt
+			result.Signer = signer
+			results = append(results, result)
+
+			if result.Success {
+				fmt.Printf("✅ Success (attempt %d/%d): %s\n", attempt, policy.MaxAttempts, result.Duration)
+				break
+			}
+
+			fmt.Printf("❌ Failed (attempt %d/%d): %s\n", attempt, policy.MaxAttempts, result.Error)
+			if attempt == policy.MaxAttempts || !policy.shouldRetry(result) {
+				break
+			}
+
+			wait, err := policy.backoff(attempt - 1)
+			if err != nil {
+				fmt.Printf("⚠️  Warning: invalid retry policy, not retrying: %v\n", err)
+				break
+			}
+			fmt.Printf("⏳ Retrying in %s\n", wait)
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				fmt.Println("🛑 Cancelled during retry backoff")
+			}
+			if ctx.Err() != nil {
+				break
+			}
 		}
 
-		// Stop on first failure if configured
+		// Stop on first failure if configured, once every attempt is spent
 		if !result.Success && runeConfig.Validation.StopOnFailure {
 			fmt.Println("🛑 Stopping execution due to failure")
 			break
@@ -241,13 +476,18 @@ func saveResults(results []CommandResult, filename string) error {
 
 // printUsage displays the usage information
 func printUsage() {
-	fmt.Println(`
+	fmt.Print(`
 🔮 LinkOps Platform Agent - Command Executor
 
 Usage:
   platform_agent <command>                    # Execute a single command
   platform_agent --rune <rune-file.json>      # Execute a rune configuration
   platform_agent --config <config-file.json>  # Load custom configuration
+  platform_agent --log-stream <file|->        # Tail live stdout/stderr lines as JSON
+  platform_agent --set key=value              # Set a ${key} template variable (repeatable)
+  platform_agent --vars-file <file.json|yaml> # Load template variables from a file
+  platform_agent --trusted-keys <dir>         # Verify rune signatures against keys in dir
+  platform_agent serve --listen :8080         # Run as an HTTP control-plane daemon
   platform_agent --help                       # Show this help
 
 Examples:
@@ -255,51 +495,222 @@ Examples:
   platform_agent "docker ps"
   platform_agent --rune deployment-rune.json
   platform_agent --config custom-config.json
+  platform_agent --rune rollout-rune.json --log-stream -
+  platform_agent --rune deployment-rune.json --vars-file prod.json --set NAMESPACE=prod
+  platform_agent --rune deployment-rune.json --trusted-keys ./trusted-keys
+  platform_agent serve --listen :8080 --db jobs.db --tokens-file api_tokens.json
+
+Daemon Mode ("serve"):
+  POST   /v1/commands          Submit a single command, returns {"id"}
+  POST   /v1/runes             Submit a rune (RuneConfig JSON body), returns {"id"}
+  GET    /v1/jobs/{id}         Job status and results
+  GET    /v1/jobs/{id}/stream  Server-Sent Events of live stdout/stderr lines
+  POST   /v1/jobs/{id}/cancel  Cancel a queued or in-progress job
+  GET    /metrics              Prometheus metrics
+
+  Every /v1/ request requires "Authorization: Bearer <token>" (/metrics
+  is served without auth); tokens and the
+  rune names each is allowed to submit are read from --tokens-file
+  ({"<token>": {"name": "...", "allowed_runes": ["*"]}}). Jobs persist in
+  a BoltDB file (--db) keyed by ULID, so results survive a restart.
 
 Safety Features:
-  ✅ Command validation and sanitization
+  ✅ Policy-based argv validation
   ✅ Path restrictions
   ✅ Timeout protection
   ✅ Comprehensive logging
   ✅ JSON result output
+  ✅ Signed rune manifests
+
+Policy (config "policy" block, replaces the old denied_commands list):
+  {
+    "rules": [
+      {"name": "no-prod-deletes", "argv_equals": {"0": "kubectl"}, "argv_contains": ["delete"], "namespace": "prod"}
+    ]
+  }
+  Each rule denies a command when every condition it sets is true:
+  "argv_equals" pins argv[index] to a value, "argv_contains" requires a
+  token to appear anywhere in argv, and "target_type"/"namespace"/"caller"
+  match the resolved Target and submitting identity. Rules are evaluated
+  against parsed argv (or, in shell mode, its whitespace-separated
+  tokens) rather than the denied-commands blocklist's substring match, so
+  "kubectl get configmap" is no longer confused with an invocation of "rm".
+
+Signed Runes:
+  --trusted-keys <dir> points at a directory of "<signer-id>.pub" files
+  (base64 ed25519 public keys). A rune file's detached signature sidecar,
+  "<rune-file>.json.sig", is a 3-line minisign-style manifest:
+    untrusted comment: signed by platform_agent
+    <base64 signature over the rune file's raw bytes>
+    signer: <signer-id>
+  When config.require_signed_runes is true, --rune refuses to run a file
+  with no sidecar or a signature that doesn't verify. The verified signer
+  id is recorded on every CommandResult produced from that rune.
+  POST /v1/runes has no manifest to check a signature against, so the
+  same setting makes the daemon reject every API-submitted rune outright
+  with 403 instead of running it unsigned.
 
 Rune Configuration Format:
   {
     "name": "Deployment Rune",
     "description": "Deploy application to Kubernetes",
     "commands": [
-      "kubectl apply -f deployment.yaml",
-      "kubectl rollout status deployment/app"
+      "kubectl apply -f ${MANIFEST} -n ${NAMESPACE:-default}",
+      {"exec": "kubectl", "args": ["get", "pods", "-n", "kube-system"]},
+      {"shell": "nslookup app.svc.cluster.local || nslookup app || ping -c1 app"},
+      {"exec": "ls", "args": ["/var/log"], "target": {"type": "ssh", "host": "10.0.0.5", "user": "ops", "key_path": "~/.ssh/id_ed25519"}}
     ],
+    "target": {"type": "kubectl", "pod": "app-0", "namespace": "default"},
+    "vars": {"MANIFEST": "deployment.yaml"},
+    "secrets": [{"name": "KUBECONFIG_TOKEN", "env": "KUBECONFIG_TOKEN"}],
     "validation": {
       "timeout_seconds": 300,
       "stop_on_failure": true
     }
   }
+
+  Each command entry accepts either a legacy plain string (parsed as
+  whitespace-separated argv), an {"exec", "args"} object for direct argv
+  invocation, or a {"shell"} object for /bin/bash -c invocation when a
+  step needs pipes, "||" fallback chains, or quoting. A rune-level
+  "target" picks the default runner (local, ssh, docker, kubectl) for
+  every step; a command's own "target" overrides it, so one rune can
+  mix local and remote steps.
+
+  "${VAR}" and "${VAR:-default}" references in exec/args/shell fields are
+  expanded before validation, from (highest precedence first) CLI --set,
+  --vars-file, the rune's own "vars" block, and the process environment.
+  An unresolved reference fails that command instead of reaching the
+  shell unexpanded. "secrets" are loaded from a file or env var and
+  injected only into the child process's environment — never logged or
+  written to a results file. Local and docker targets receive them (docker
+  via "docker exec -e"); ssh and kubectl targets refuse to run a step
+  while secrets are set.
+
+  A rune-level or per-command "retry" block
+  ({"max_attempts", "initial_backoff", "max_backoff", "multiplier",
+  "retry_on_exit_codes", "retry_on_stderr_regex"}) retries a failing step
+  with exponential backoff and jitter; each attempt is recorded as its
+  own result with an "attempt" number, and "stop_on_failure" only halts
+  the rune once every attempt for a step is exhausted.
 `)
 }
 
+// extractLogStreamFlag pulls a "--log-stream <dest>" pair out of args,
+// wherever it appears, and returns the destination plus the remaining args.
+func extractLogStreamFlag(args []string) (string, []string) {
+	for i, a := range args {
+		if a == "--log-stream" && i+1 < len(args) {
+			dest := args[i+1]
+			rest := append([]string{}, args[:i]...)
+			rest = append(rest, args[i+2:]...)
+			return dest, rest
+		}
+	}
+	return "", args
+}
+
+// extractSetFlags pulls every "--set key=value" pair out of args, wherever
+// they appear, and returns them as a map plus the remaining args.
+func extractSetFlags(args []string) (map[string]string, []string) {
+	set := make(map[string]string)
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--set" && i+1 < len(args) {
+			key, value, _ := strings.Cut(args[i+1], "=")
+			set[key] = value
+			i++
+			continue
+		}
+		rest = append(rest, args[i])
+	}
+	return set, rest
+}
+
+// extractVarsFileFlag pulls a "--vars-file <path>" pair out of args,
+// wherever it appears, and returns the loaded vars plus the remaining args.
+func extractVarsFileFlag(args []string) (map[string]string, []string, error) {
+	for i, a := range args {
+		if a == "--vars-file" && i+1 < len(args) {
+			vars, err := parseVarsFile(args[i+1])
+			if err != nil {
+				return nil, nil, err
+			}
+			rest := append([]string{}, args[:i]...)
+			rest = append(rest, args[i+2:]...)
+			return vars, rest, nil
+		}
+	}
+	return nil, args, nil
+}
+
+// extractTrustedKeysFlag pulls a "--trusted-keys <dir>" pair out of args,
+// wherever it appears, and returns the directory plus the remaining args.
+func extractTrustedKeysFlag(args []string) (string, []string) {
+	for i, a := range args {
+		if a == "--trusted-keys" && i+1 < len(args) {
+			dir := args[i+1]
+			rest := append([]string{}, args[:i]...)
+			rest = append(rest, args[i+2:]...)
+			return dir, rest
+		}
+	}
+	return "", args
+}
+
 func main() {
-	if len(os.Args) < 2 {
+	args := os.Args[1:]
+
+	// Handle daemon mode
+	if len(args) > 0 && args[0] == "serve" {
+		cmdServe(args[1:])
+		return
+	}
+
+	var logger Logger
+	logStreamDest, args := extractLogStreamFlag(args)
+	if logStreamDest != "" {
+		fileLogger, err := newFileLogger(logStreamDest)
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		logger = fileLogger
+	}
+
+	cliVars, args := extractSetFlags(args)
+	fileVars, args, err := extractVarsFileFlag(args)
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	trustedKeysDir, args := extractTrustedKeysFlag(args)
+
+	// The CLI has no per-user authentication, so every local invocation
+	// shares one caller identity for policy rules; the daemon uses each
+	// request's bearer token name instead (see serve.go).
+	const cliCaller = "cli"
+
+	if len(args) < 1 {
 		printUsage()
 		os.Exit(1)
 	}
 
 	// Handle help
-	if os.Args[1] == "--help" || os.Args[1] == "-h" {
+	if args[0] == "--help" || args[0] == "-h" {
 		printUsage()
 		return
 	}
 
 	// Handle rune execution
-	if os.Args[1] == "--rune" {
-		if len(os.Args) < 3 {
+	if args[0] == "--rune" {
+		if len(args) < 2 {
 			fmt.Println("❌ Error: Rune file not specified")
 			fmt.Println("Usage: platform_agent --rune <rune-file.json>")
 			os.Exit(1)
 		}
 
-		results, err := executeRune(os.Args[2])
+		results, err := executeRune(context.Background(), args[1], cliVars, fileVars, trustedKeysDir, cliCaller, logger)
 		if err != nil {
 			fmt.Printf("❌ Error executing rune: %v\n", err)
 			os.Exit(1)
@@ -325,10 +736,21 @@ func main() {
 	}
 
 	// Handle single command execution
-	cmd := os.Args[1]
-	fmt.Printf("🔮 Executing: %s\n", cmd)
+	cmdStr := args[0]
+	fmt.Printf("🔮 Executing: %s\n", cmdStr)
+
+	var rawCmd Command
+	if err := rawCmd.UnmarshalJSON([]byte(strconv.Quote(cmdStr))); err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+	cmd, err := expandCommand(rawCmd, mergeVars(fileVars, cliVars))
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	result := executeCommand(cmd, config.MaxTimeout)
+	result := executeCommand(context.Background(), cmd, config.MaxTimeout, Target{}, nil, cliCaller, logger)
 
 	// Print result
 	if result.Success {
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestSanitizeCommand_DeniesMultiWordPattern locks in the fix for a
+// regression introduced when sanitizeCommand first shipped: checking each
+// argv element individually against a denylist of whole command strings
+// meant a multi-word pattern like "kill -9" could never match, since
+// {"exec":"kill","args":["-9","1234"]} splits "kill" and "-9" into
+// separate argv entries that are each compared whole against the pattern.
+// Policy.ArgvEquals (see policy.go) matches per-index instead, so this
+// must now correctly deny.
+func TestSanitizeCommand_DeniesMultiWordPattern(t *testing.T) {
+	cmd := Command{Mode: "exec", Exec: "kill", Args: []string{"-9", "1234"}}
+	ok, reason := sanitizeCommand(cmd, Target{}, "cli")
+	if ok {
+		t.Fatalf("expected \"kill -9\" to be denied, got allowed")
+	}
+	if reason == "" {
+		t.Fatalf("expected a denial reason")
+	}
+}
+
+// TestSanitizeCommand_AllowsUnrelatedArgv guards against the opposite
+// failure mode: over-blocking a command that merely shares a token with a
+// denied pattern in the wrong position.
+func TestSanitizeCommand_AllowsUnrelatedArgv(t *testing.T) {
+	cmd := Command{Mode: "exec", Exec: "echo", Args: []string{"kill", "-9"}}
+	ok, reason := sanitizeCommand(cmd, Target{}, "cli")
+	if !ok {
+		t.Fatalf("expected \"echo kill -9\" to be allowed, got denied: %s", reason)
+	}
+}
+
+// TestExecuteRuneConfig_CancelInterruptsRetryBackoff locks in the fix for a
+// context leak: the inter-attempt backoff used time.Sleep(wait), which
+// ignored ctx entirely, so a cancel fired while a retry was sleeping (up to
+// max_backoff, 30s by default) had no effect until the sleep finished. A
+// failing step with a long backoff must now return promptly once its
+// parent context is cancelled, not after the full wait.
+func TestExecuteRuneConfig_CancelInterruptsRetryBackoff(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	runeConfig := RuneConfig{
+		Name: "test-rune",
+		Commands: []Command{
+			{Mode: "exec", Exec: "false"},
+		},
+		Retry: RetryPolicy{MaxAttempts: 5, InitialBackoff: "10s", MaxBackoff: "10s"},
+	}
+
+	start := time.Now()
+	executeRuneConfig(ctx, runeConfig, nil, nil, "cli", "", nil)
+	elapsed := time.Since(start)
+
+	if elapsed > 2*time.Second {
+		t.Fatalf("executeRuneConfig took %s to return after cancellation, want well under the 10s backoff", elapsed)
+	}
+}
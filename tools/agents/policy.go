@@ -0,0 +1,97 @@
+package main
+
+import "path/filepath"
+
+// PolicyRule expresses one deny condition over a command's parsed argv,
+// its target runner, and the caller identity that submitted it. This is a
+// minimal rule evaluator — argv-position equality, argv membership,
+// target type/namespace, and caller — rather than a full OPA/CEL runtime,
+// but it covers the same shape of rule a CEL expression like
+// `argv[0] == "kubectl" && "delete" in argv && namespace == "prod"` would
+// express, without the over-blocking (any arg containing "rm") or
+// under-blocking (quoting, `$(rm ...)` substitution) of substring matching
+// against the whole command line.
+type PolicyRule struct {
+	Name string `json:"name"`
+	// ArgvEquals requires argv[index] == value for every entry, e.g.
+	// {0: "kubectl", 1: "delete"}.
+	ArgvEquals map[int]string `json:"argv_equals,omitempty"`
+	// ArgvContains requires each value to appear somewhere in argv.
+	ArgvContains []string `json:"argv_contains,omitempty"`
+	// TargetType, if set, requires the command's resolved Target.Type to
+	// match ("local" also matches the zero value).
+	TargetType string `json:"target_type,omitempty"`
+	// Namespace, if set, requires the resolved Target.Namespace to match.
+	Namespace string `json:"namespace,omitempty"`
+	// Caller, if set, requires the submitting identity to match ("*"
+	// matches any caller).
+	Caller string `json:"caller,omitempty"`
+}
+
+func (r PolicyRule) matches(argv []string, target Target, caller string) bool {
+	if r.TargetType != "" {
+		actual := target.Type
+		if actual == "" {
+			actual = "local"
+		}
+		if actual != r.TargetType {
+			return false
+		}
+	}
+	if r.Namespace != "" && r.Namespace != target.Namespace {
+		return false
+	}
+	if r.Caller != "" && r.Caller != "*" && r.Caller != caller {
+		return false
+	}
+	for index, want := range r.ArgvEquals {
+		if index < 0 || index >= len(argv) || argvToken(argv, index) != want {
+			return false
+		}
+	}
+	for _, want := range r.ArgvContains {
+		found := false
+		for i := range argv {
+			if argvToken(argv, i) == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// argvToken returns the argv entry at index as it should be compared
+// against a rule value: argv[0] is the executable and may be path-
+// qualified ("/bin/rm" for a bare "rm" rule), so it is compared by its
+// base name; every other position is compared verbatim.
+func argvToken(argv []string, index int) string {
+	if index == 0 {
+		return filepath.Base(argv[0])
+	}
+	return argv[index]
+}
+
+// Policy is an ordered set of deny rules; the first matching rule wins.
+type Policy struct {
+	Rules []PolicyRule `json:"rules"`
+}
+
+// Evaluate reports whether argv is allowed to run against target on
+// behalf of caller. It replaces the old substring-based DeniedCommands
+// blocklist.
+func (p Policy) Evaluate(argv []string, target Target, caller string) (bool, string) {
+	for _, rule := range p.Rules {
+		if rule.matches(argv, target, caller) {
+			name := rule.Name
+			if name == "" {
+				name = "unnamed rule"
+			}
+			return false, "denied by policy: " + name
+		}
+	}
+	return true, ""
+}
@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestPolicy_Evaluate(t *testing.T) {
+	policy := Policy{Rules: []PolicyRule{
+		{Name: "no-kill-9", ArgvEquals: map[int]string{0: "kill", 1: "-9"}},
+		{Name: "no-sudo-rm", ArgvEquals: map[int]string{0: "sudo", 1: "rm"}},
+		{Name: "no-prod-deletes", ArgvEquals: map[int]string{0: "kubectl"}, ArgvContains: []string{"delete"}, Namespace: "prod"},
+		{Name: "blocked-caller", Caller: "untrusted"},
+	}}
+
+	cases := []struct {
+		name   string
+		argv   []string
+		target Target
+		caller string
+		want   bool
+	}{
+		{name: "denies exact multi-word pattern", argv: []string{"kill", "-9", "1234"}, want: false},
+		{name: "denies path-qualified argv[0]", argv: []string{"/bin/kill", "-9", "1234"}, want: false},
+		{name: "allows unrelated tokens in other positions", argv: []string{"echo", "kill", "-9"}, want: true},
+		{name: "denies sudo rm", argv: []string{"sudo", "rm", "-rf", "/"}, want: false},
+		{name: "allows plain rm", argv: []string{"rm", "-rf", "/tmp/x"}, want: true},
+		{name: "denies prod kubectl delete", argv: []string{"kubectl", "delete", "pod", "x"}, target: Target{Namespace: "prod"}, want: false},
+		{name: "allows staging kubectl delete", argv: []string{"kubectl", "delete", "pod", "x"}, target: Target{Namespace: "staging"}, want: true},
+		{name: "allows kubectl get in prod", argv: []string{"kubectl", "get", "pods"}, target: Target{Namespace: "prod"}, want: true},
+		{name: "denies blocked caller regardless of command", argv: []string{"echo", "hi"}, caller: "untrusted", want: false},
+		{name: "allows trusted caller", argv: []string{"echo", "hi"}, caller: "cli", want: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, reason := policy.Evaluate(tc.argv, tc.target, tc.caller)
+			if ok != tc.want {
+				t.Fatalf("got allowed=%v (reason %q), want allowed=%v", ok, reason, tc.want)
+			}
+			if !ok && reason == "" {
+				t.Fatalf("expected a denial reason")
+			}
+		})
+	}
+}
+
+func TestPolicy_Evaluate_FirstMatchWins(t *testing.T) {
+	policy := Policy{Rules: []PolicyRule{
+		{Name: "first", ArgvEquals: map[int]string{0: "rm"}},
+		{Name: "second", ArgvEquals: map[int]string{0: "rm"}},
+	}}
+	_, reason := policy.Evaluate([]string{"rm", "-rf", "/"}, Target{}, "")
+	if reason != "denied by policy: first" {
+		t.Fatalf("got reason %q, want the first matching rule", reason)
+	}
+}
+
+func TestPolicy_Evaluate_EmptyPolicyAllowsEverything(t *testing.T) {
+	var policy Policy
+	ok, _ := policy.Evaluate([]string{"rm", "-rf", "/"}, Target{}, "")
+	if !ok {
+		t.Fatalf("expected an empty policy to allow everything")
+	}
+}
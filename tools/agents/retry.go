@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"time"
+)
+
+// RetryPolicy controls how many times a step is retried, and how long to
+// wait between attempts, before it is declared failed. This is essential
+// for commands like `kubectl rollout status` or an HTTP health check that
+// legitimately need a few tries before succeeding.
+type RetryPolicy struct {
+	MaxAttempts        int     `json:"max_attempts,omitempty"`
+	InitialBackoff     string  `json:"initial_backoff,omitempty"`
+	MaxBackoff         string  `json:"max_backoff,omitempty"`
+	Multiplier         float64 `json:"multiplier,omitempty"`
+	RetryOnExitCodes   []int   `json:"retry_on_exit_codes,omitempty"`
+	RetryOnStderrRegex string  `json:"retry_on_stderr_regex,omitempty"`
+}
+
+// defaultRetryPolicy runs a step exactly once, matching pre-existing
+// behavior for runes that don't opt into retries.
+var defaultRetryPolicy = RetryPolicy{MaxAttempts: 1}
+
+// withDefaults fills in zero fields (one attempt, 500ms initial backoff,
+// 30s max backoff, multiplier 2) so callers only need to set the fields
+// they care about.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 1
+	}
+	if p.InitialBackoff == "" {
+		p.InitialBackoff = "500ms"
+	}
+	if p.MaxBackoff == "" {
+		p.MaxBackoff = "30s"
+	}
+	if p.Multiplier <= 0 {
+		p.Multiplier = 2
+	}
+	return p
+}
+
+// backoff computes min(initial*multiplier^attempt, max) with up to 20%
+// jitter, for the sleep before the given zero-indexed retry attempt.
+func (p RetryPolicy) backoff(attempt int) (time.Duration, error) {
+	initial, err := time.ParseDuration(p.InitialBackoff)
+	if err != nil {
+		return 0, fmt.Errorf("invalid initial_backoff: %v", err)
+	}
+	max, err := time.ParseDuration(p.MaxBackoff)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max_backoff: %v", err)
+	}
+
+	d := time.Duration(float64(initial) * math.Pow(p.Multiplier, float64(attempt)))
+	if d > max {
+		d = max
+	}
+	jitter := time.Duration(rand.Int63n(int64(d)/5 + 1))
+	return d + jitter, nil
+}
+
+// shouldRetry reports whether a failed result qualifies for another
+// attempt under this policy's exit-code and stderr-regex rules. With
+// neither rule set, any failure is retried (up to MaxAttempts).
+func (p RetryPolicy) shouldRetry(result CommandResult) bool {
+	if len(p.RetryOnExitCodes) == 0 && p.RetryOnStderrRegex == "" {
+		return true
+	}
+	for _, code := range p.RetryOnExitCodes {
+		if result.ExitCode == code {
+			return true
+		}
+	}
+	if p.RetryOnStderrRegex != "" {
+		if re, err := regexp.Compile(p.RetryOnStderrRegex); err == nil {
+			if re.MatchString(result.Stderr) || re.MatchString(result.Error) {
+				return true
+			}
+		}
+	}
+	return false
+}
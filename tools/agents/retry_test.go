@@ -0,0 +1,104 @@
+package main
+
+import "testing"
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: "1s", MaxBackoff: "4s", Multiplier: 2}.withDefaults()
+
+	// backoff adds up to 20% jitter, so assert on the pre-jitter floor and
+	// the max ceiling rather than an exact duration.
+	cases := []struct {
+		attempt  int
+		wantBase int64 // pre-jitter nanoseconds
+		wantCap  int64 // MaxBackoff nanoseconds
+	}{
+		{attempt: 0, wantBase: int64(1e9), wantCap: int64(4e9)},
+		{attempt: 1, wantBase: int64(2e9), wantCap: int64(4e9)},
+		{attempt: 2, wantBase: int64(4e9), wantCap: int64(4e9)}, // capped at max
+		{attempt: 5, wantBase: int64(4e9), wantCap: int64(4e9)}, // still capped
+	}
+
+	for _, tc := range cases {
+		d, err := policy.backoff(tc.attempt)
+		if err != nil {
+			t.Fatalf("attempt %d: unexpected error: %v", tc.attempt, err)
+		}
+		if int64(d) < tc.wantBase {
+			t.Fatalf("attempt %d: got %s, want at least %d ns", tc.attempt, d, tc.wantBase)
+		}
+		if int64(d) > tc.wantCap+tc.wantCap/5 {
+			t.Fatalf("attempt %d: got %s, want at most %d ns plus jitter", tc.attempt, d, tc.wantCap)
+		}
+	}
+}
+
+func TestRetryPolicy_Backoff_InvalidDuration(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: "not-a-duration", MaxBackoff: "30s", Multiplier: 2}
+	if _, err := policy.backoff(0); err == nil {
+		t.Fatalf("expected an error for an invalid initial_backoff")
+	}
+}
+
+func TestRetryPolicy_ShouldRetry(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy RetryPolicy
+		result CommandResult
+		want   bool
+	}{
+		{
+			name:   "no rules retries any failure",
+			policy: RetryPolicy{},
+			result: CommandResult{ExitCode: 1},
+			want:   true,
+		},
+		{
+			name:   "matching exit code retries",
+			policy: RetryPolicy{RetryOnExitCodes: []int{1, 2}},
+			result: CommandResult{ExitCode: 2},
+			want:   true,
+		},
+		{
+			name:   "non-matching exit code does not retry",
+			policy: RetryPolicy{RetryOnExitCodes: []int{1, 2}},
+			result: CommandResult{ExitCode: 3},
+			want:   false,
+		},
+		{
+			name:   "matching stderr regex retries",
+			policy: RetryPolicy{RetryOnStderrRegex: "connection refused"},
+			result: CommandResult{Stderr: "dial tcp: connection refused"},
+			want:   true,
+		},
+		{
+			name:   "non-matching stderr regex does not retry",
+			policy: RetryPolicy{RetryOnStderrRegex: "connection refused"},
+			result: CommandResult{Stderr: "permission denied"},
+			want:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.policy.shouldRetry(tc.result); got != tc.want {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_WithDefaults(t *testing.T) {
+	p := RetryPolicy{}.withDefaults()
+	if p.MaxAttempts != 1 {
+		t.Errorf("MaxAttempts: got %d, want 1", p.MaxAttempts)
+	}
+	if p.InitialBackoff != "500ms" {
+		t.Errorf("InitialBackoff: got %q, want \"500ms\"", p.InitialBackoff)
+	}
+	if p.MaxBackoff != "30s" {
+		t.Errorf("MaxBackoff: got %q, want \"30s\"", p.MaxBackoff)
+	}
+	if p.Multiplier != 2 {
+		t.Errorf("Multiplier: got %v, want 2", p.Multiplier)
+	}
+}
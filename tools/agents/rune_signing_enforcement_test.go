@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestExecuteRuneConfig_RequireSignedRunesRejectsUnsigned locks in the fix
+// for an API bypass: executeRuneConfig is the shared core behind both
+// --rune (which verifies a signature before calling in) and
+// POST /v1/runes (which has no manifest to verify against, so it always
+// passed signer=""). Without this check, config.RequireSignedRunes had no
+// effect on a rune submitted over the API even though it was enforced for
+// --rune.
+func TestExecuteRuneConfig_RequireSignedRunesRejectsUnsigned(t *testing.T) {
+	config.RequireSignedRunes = true
+	defer func() { config.RequireSignedRunes = false }()
+
+	runeConfig := RuneConfig{Name: "test-rune", Commands: []Command{
+		{Mode: "exec", Exec: "echo", Args: []string{"hi"}},
+	}}
+
+	_, err := executeRuneConfig(context.Background(), runeConfig, nil, nil, "cli", "", nil)
+	if err == nil {
+		t.Fatalf("expected an error when require_signed_runes is true and no signer is provided")
+	}
+	if !strings.Contains(err.Error(), "signer") {
+		t.Fatalf("got error %q, want it to mention the missing signer", err)
+	}
+}
+
+// TestExecuteRuneConfig_RequireSignedRunesAllowsVerifiedSigner ensures the
+// same check doesn't block the legitimate --rune path, where a verified
+// signer id is always non-empty by the time executeRuneConfig is called.
+func TestExecuteRuneConfig_RequireSignedRunesAllowsVerifiedSigner(t *testing.T) {
+	config.RequireSignedRunes = true
+	defer func() { config.RequireSignedRunes = false }()
+
+	runeConfig := RuneConfig{Name: "test-rune", Commands: []Command{
+		{Mode: "exec", Exec: "echo", Args: []string{"hi"}},
+	}}
+
+	results, err := executeRuneConfig(context.Background(), runeConfig, nil, nil, "cli", "ops-team", nil)
+	if err != nil {
+		t.Fatalf("unexpected error with a verified signer: %v", err)
+	}
+	if len(results) != 1 || results[0].Signer != "ops-team" {
+		t.Fatalf("expected one result recording the verified signer, got %+v", results)
+	}
+}
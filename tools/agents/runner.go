@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Target selects where a Command actually runs. The zero value (Type "" or
+// "local") runs in the current process's shell/host, matching the
+// pre-existing behavior.
+type Target struct {
+	Type string `json:"type,omitempty"` // "local" (default), "ssh", "docker", "kubectl"
+
+	// ssh
+	Host    string `json:"host,omitempty"`
+	User    string `json:"user,omitempty"`
+	KeyPath string `json:"key_path,omitempty"`
+
+	// docker
+	Container string `json:"container,omitempty"`
+
+	// kubectl
+	Pod       string `json:"pod,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// IsLocal reports whether this target is the default (in-process) runner.
+func (t Target) IsLocal() bool {
+	return t.Type == "" || t.Type == "local"
+}
+
+// Runner executes an already-built *exec.Cmd somewhere — the local host,
+// over SSH, inside a running Docker container, or inside a Kubernetes pod —
+// and returns a CommandResult. This mirrors the minikube CommandRunner
+// pattern: callers build the command once and let the Runner decide how to
+// carry it out. secrets is passed alongside cmd rather than folded into
+// cmd.Env by the caller, because only LocalRunner can honor env vars by
+// simply executing cmd as built — every other backend re-executes the
+// command through a separate process (ssh/docker/kubectl) that doesn't
+// inherit cmd.Env at all, so each Runner needs the secrets explicitly to
+// either inject them in a backend-appropriate way or refuse to run rather
+// than silently dropping them.
+type Runner interface {
+	RunCmd(ctx context.Context, cmd *exec.Cmd, secrets map[string]string) (CommandResult, error)
+}
+
+// Line is one line of live output from a running command, forwarded to a
+// Logger as it is produced rather than buffered until the command exits.
+type Line struct {
+	Proc string    `json:"proc"`
+	Time time.Time `json:"time"`
+	Out  bool      `json:"out,omitempty"`
+	Err  bool      `json:"err,omitempty"`
+	Text string    `json:"text"`
+}
+
+// Logger receives streamed output lines as a command runs, so a UI or
+// --log-stream file can tail execution instead of waiting for completion.
+type Logger interface {
+	Write(line Line)
+}
+
+// loggerContextKey carries a Logger on a Context, so concurrent executions
+// (e.g. the daemon's per-job streams) each get their own destination
+// instead of sharing mutable global state.
+type loggerContextKey struct{}
+
+// contextWithLogger attaches logger to ctx for collectOutput to pick up.
+func contextWithLogger(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+func loggerFromContext(ctx context.Context) Logger {
+	logger, _ := ctx.Value(loggerContextKey{}).(Logger)
+	return logger
+}
+
+// secretsContextKey carries the loaded secrets map on a Context, so
+// collectOutput can redact them from each streamed line as it happens,
+// not just from the buffered CommandResult once the command exits.
+type secretsContextKey struct{}
+
+// contextWithSecrets attaches secrets to ctx for collectOutput to pick up.
+func contextWithSecrets(ctx context.Context, secrets map[string]string) context.Context {
+	return context.WithValue(ctx, secretsContextKey{}, secrets)
+}
+
+func secretsFromContext(ctx context.Context) map[string]string {
+	secrets, _ := ctx.Value(secretsContextKey{}).(map[string]string)
+	return secrets
+}
+
+// newRunner selects a Runner implementation for the given target.
+func newRunner(t Target) (Runner, error) {
+	switch t.Type {
+	case "", "local":
+		return LocalRunner{}, nil
+	case "ssh":
+		if t.Host == "" {
+			return nil, fmt.Errorf("ssh target requires host")
+		}
+		return SSHRunner{Host: t.Host, User: t.User, KeyPath: t.KeyPath}, nil
+	case "docker":
+		if t.Container == "" {
+			return nil, fmt.Errorf("docker target requires container")
+		}
+		return DockerRunner{Container: t.Container}, nil
+	case "kubectl":
+		if t.Pod == "" {
+			return nil, fmt.Errorf("kubectl target requires pod")
+		}
+		return KubectlRunner{Pod: t.Pod, Namespace: t.Namespace, Container: t.Container}, nil
+	default:
+		return nil, fmt.Errorf("unknown target type: %s", t.Type)
+	}
+}
+
+// collectOutput reads stdout and stderr concurrently until both are
+// drained, streaming each line to the Logger attached to ctx (if any) as it
+// is produced, and returns the separated streams plus a merged,
+// interleaved-by-arrival view. It is shared by every Runner implementation
+// that exposes its remote process as a pair of io.Readers, whether that's
+// an *exec.Cmd's pipes or an *ssh.Session's.
+func collectOutput(ctx context.Context, proc string, stdout, stderr io.Reader) (stdoutStr, stderrStr, mergedStr string) {
+	logger := loggerFromContext(ctx)
+	secrets := secretsFromContext(ctx)
+
+	var mu sync.Mutex
+	var stdoutBuf, stderrBuf, mergedBuf strings.Builder
+	collect := func(r io.Reader, isErr bool) {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			text := scanner.Text()
+			mu.Lock()
+			if isErr {
+				stderrBuf.WriteString(text + "\n")
+			} else {
+				stdoutBuf.WriteString(text + "\n")
+			}
+			mergedBuf.WriteString(text + "\n")
+			mu.Unlock()
+			if logger != nil {
+				logger.Write(Line{Proc: proc, Time: time.Now(), Out: !isErr, Err: isErr, Text: redactSecrets(text, secrets)})
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); collect(stdout, false) }()
+	go func() { defer wg.Done(); collect(stderr, true) }()
+	wg.Wait()
+
+	return stdoutBuf.String(), stderrBuf.String(), mergedBuf.String()
+}
+
+// runAndCollect runs command, streaming each output line to the Logger
+// attached to ctx (if any) as it is produced, and fills in the
+// timing/output/exit-code fields shared by every Runner implementation.
+// Stdout and Stderr are captured separately; Output keeps the merged,
+// interleaved-by-arrival view for compatibility with callers that only
+// looked at CombinedOutput before.
+func runAndCollect(ctx context.Context, command *exec.Cmd) (CommandResult, error) {
+	var result CommandResult
+
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		return result, fmt.Errorf("failed to attach stdout: %v", err)
+	}
+	stderr, err := command.StderrPipe()
+	if err != nil {
+		return result, fmt.Errorf("failed to attach stderr: %v", err)
+	}
+
+	if err := command.Start(); err != nil {
+		return result, fmt.Errorf("failed to start command: %v", err)
+	}
+
+	result.Stdout, result.Stderr, result.Output = collectOutput(ctx, filepath.Base(command.Path), stdout, stderr)
+
+	waitErr := command.Wait()
+	if waitErr != nil {
+		result.Success = false
+		result.Error = waitErr.Error()
+		if exitError, ok := waitErr.(*exec.ExitError); ok {
+			result.ExitCode = exitError.ExitCode()
+		} else {
+			result.ExitCode = -1
+		}
+	} else {
+		result.Success = true
+		result.ExitCode = 0
+	}
+	return result, nil
+}
+
+// LocalRunner executes the command in the agent's own process, on the
+// local host. This is the pre-existing behavior.
+type LocalRunner struct{}
+
+func (LocalRunner) RunCmd(ctx context.Context, cmd *exec.Cmd, secrets map[string]string) (CommandResult, error) {
+	if len(secrets) > 0 {
+		cmd.Env = os.Environ()
+		for name, value := range secrets {
+			cmd.Env = append(cmd.Env, name+"="+value)
+		}
+	}
+	return runAndCollect(ctx, cmd)
+}
+
+// SSHRunner dials a remote host and runs the command there over SSH, using
+// golang.org/x/crypto/ssh directly rather than shelling out to the host's
+// ssh client, so it has no dependency on a system ssh binary or its config.
+type SSHRunner struct {
+	Host    string
+	User    string
+	KeyPath string
+}
+
+// sshClientConfig builds the auth and host-key verification config for a
+// dial: the private key at KeyPath authenticates us to the host, and
+// known_hosts (~/.ssh/known_hosts) authenticates the host to us. There is
+// no --insecure escape hatch here on purpose — a target with an unrecorded
+// or changed host key should fail closed rather than run commands against
+// a possibly-spoofed remote.
+func (r SSHRunner) sshClientConfig() (*ssh.ClientConfig, error) {
+	keyData, err := os.ReadFile(r.KeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ssh key %q: %v", r.KeyPath, err)
+	}
+	signer, err := ssh.ParsePrivateKey(keyData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ssh key %q: %v", r.KeyPath, err)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve home directory for known_hosts: %v", err)
+	}
+	hostKeyCallback, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts: %v", err)
+	}
+
+	return &ssh.ClientConfig{
+		User:            r.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         10 * time.Second,
+	}, nil
+}
+
+func (r SSHRunner) RunCmd(ctx context.Context, cmd *exec.Cmd, secrets map[string]string) (CommandResult, error) {
+	var result CommandResult
+
+	if len(secrets) > 0 {
+		return result, fmt.Errorf("ssh target %s does not support injected secrets; refusing to run the command without them", r.Host)
+	}
+
+	cfg, err := r.sshClientConfig()
+	if err != nil {
+		return result, err
+	}
+
+	addr := r.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(r.Host, "22")
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return result, fmt.Errorf("failed to dial %s: %v", addr, err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, cfg)
+	if err != nil {
+		conn.Close()
+		return result, fmt.Errorf("ssh handshake with %s failed: %v", addr, err)
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return result, fmt.Errorf("failed to open ssh session on %s: %v", addr, err)
+	}
+	defer session.Close()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return result, fmt.Errorf("failed to attach stdout: %v", err)
+	}
+	stderr, err := session.StderrPipe()
+	if err != nil {
+		return result, fmt.Errorf("failed to attach stderr: %v", err)
+	}
+
+	// ssh.Session has no context-aware Run/Wait, so a cancelled ctx closes
+	// the session out from under it instead, which unblocks Wait with an
+	// error.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.Close()
+		case <-done:
+		}
+	}()
+
+	if err := session.Start(shellQuoteArgv(cmd.Args)); err != nil {
+		return result, fmt.Errorf("failed to start remote command: %v", err)
+	}
+
+	result.Stdout, result.Stderr, result.Output = collectOutput(ctx, r.Host, stdout, stderr)
+
+	waitErr := session.Wait()
+	if waitErr != nil {
+		result.Success = false
+		result.Error = waitErr.Error()
+		if exitError, ok := waitErr.(*ssh.ExitError); ok {
+			result.ExitCode = exitError.ExitStatus()
+		} else {
+			result.ExitCode = -1
+		}
+	} else {
+		result.Success = true
+		result.ExitCode = 0
+	}
+	return result, nil
+}
+
+// DockerRunner execs the command inside a running container by name or id.
+type DockerRunner struct {
+	Container string
+}
+
+func (r DockerRunner) RunCmd(ctx context.Context, cmd *exec.Cmd, secrets map[string]string) (CommandResult, error) {
+	args := []string{"exec"}
+	for name, value := range secrets {
+		args = append(args, "-e", name+"="+value)
+	}
+	args = append(args, r.Container)
+	args = append(args, cmd.Args...)
+	remote := exec.CommandContext(ctx, "docker", args...)
+	return runAndCollect(ctx, remote)
+}
+
+// KubectlRunner execs the command inside a pod/container in a namespace.
+type KubectlRunner struct {
+	Pod       string
+	Namespace string
+	Container string
+}
+
+func (r KubectlRunner) RunCmd(ctx context.Context, cmd *exec.Cmd, secrets map[string]string) (CommandResult, error) {
+	if len(secrets) > 0 {
+		return CommandResult{}, fmt.Errorf("kubectl target %s/%s does not support injected secrets; refusing to run the command without them", r.Namespace, r.Pod)
+	}
+
+	args := []string{"exec"}
+	if r.Namespace != "" {
+		args = append(args, "-n", r.Namespace)
+	}
+	args = append(args, r.Pod)
+	if r.Container != "" {
+		args = append(args, "-c", r.Container)
+	}
+	args = append(args, "--")
+	args = append(args, cmd.Args...)
+	remote := exec.CommandContext(ctx, "kubectl", args...)
+	return runAndCollect(ctx, remote)
+}
+
+// shellQuoteArgv joins argv into a single shell-safe command line for
+// transports (like SSH) that take a command string rather than an argv.
+func shellQuoteArgv(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, a := range argv {
+		quoted[i] = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
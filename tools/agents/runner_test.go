@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSSHRunner_RunCmd_RejectsSecrets locks in the fix for a silent secret
+// drop: SSHRunner only ever saw cmd.Args, never cmd.Env, so a rune with a
+// secrets block and an ssh target used to run the remote command with no
+// error and no secret. It must now fail loudly instead.
+func TestSSHRunner_RunCmd_RejectsSecrets(t *testing.T) {
+	r := SSHRunner{Host: "example.invalid"}
+	_, err := r.RunCmd(context.Background(), exec.Command("echo", "hi"), map[string]string{"API_TOKEN": "x"})
+	if err == nil {
+		t.Fatalf("expected an error when secrets are required over ssh")
+	}
+	if !strings.Contains(err.Error(), "secrets") {
+		t.Fatalf("got error %q, want it to mention secrets", err)
+	}
+}
+
+// TestKubectlRunner_RunCmd_RejectsSecrets mirrors
+// TestSSHRunner_RunCmd_RejectsSecrets for the kubectl backend, which has
+// no equivalent of `docker exec -e` to inject an env var into an already-
+// running pod's exec session.
+func TestKubectlRunner_RunCmd_RejectsSecrets(t *testing.T) {
+	r := KubectlRunner{Pod: "web-0", Namespace: "prod"}
+	_, err := r.RunCmd(context.Background(), exec.Command("echo", "hi"), map[string]string{"API_TOKEN": "x"})
+	if err == nil {
+		t.Fatalf("expected an error when secrets are required over kubectl exec")
+	}
+	if !strings.Contains(err.Error(), "secrets") {
+		t.Fatalf("got error %q, want it to mention secrets", err)
+	}
+}
+
+// TestDockerRunner_RunCmd_InjectsSecretsAsDockerExecFlags locks in that
+// DockerRunner, unlike ssh/kubectl, actually forwards secrets to the
+// remote side using `docker exec -e KEY=VALUE` rather than silently
+// dropping or refusing them.
+func TestDockerRunner_RunCmd_InjectsSecretsAsDockerExecFlags(t *testing.T) {
+	fakeDocker := filepath.Join(t.TempDir(), "docker")
+	if err := os.WriteFile(fakeDocker, []byte("#!/bin/sh\necho \"$@\"\n"), 0755); err != nil {
+		t.Fatalf("failed to write fake docker: %v", err)
+	}
+	t.Setenv("PATH", filepath.Dir(fakeDocker)+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	r := DockerRunner{Container: "web"}
+	result, err := r.RunCmd(context.Background(), exec.Command("printenv", "API_TOKEN"), map[string]string{"API_TOKEN": "sk-super-secret"})
+	if err != nil {
+		t.Fatalf("RunCmd: %v", err)
+	}
+	if !strings.Contains(result.Output, "-e API_TOKEN=sk-super-secret") {
+		t.Fatalf("expected docker exec to receive the secret as an -e flag, got %q", result.Output)
+	}
+}
+
+type capturingLogger struct {
+	lines []Line
+}
+
+func (c *capturingLogger) Write(line Line) {
+	c.lines = append(c.lines, line)
+}
+
+// TestCollectOutput_RedactsSecretsFromStreamedLines locks in the fix for a
+// leak where collectOutput forwarded each raw output line to logger.Write
+// as the command ran, before secrets were redacted — so a --log-stream
+// file or the daemon's SSE feed could show a secret in clear text even
+// though the buffered CommandResult it was drawn from had it redacted.
+func TestCollectOutput_RedactsSecretsFromStreamedLines(t *testing.T) {
+	logger := &capturingLogger{}
+	secrets := map[string]string{"API_TOKEN": "sk-super-secret"}
+	ctx := contextWithLogger(context.Background(), logger)
+	ctx = contextWithSecrets(ctx, secrets)
+
+	stdout := strings.NewReader("token is sk-super-secret\nharmless line\n")
+	stderr := strings.NewReader("")
+
+	collectOutput(ctx, "test", stdout, stderr)
+
+	for _, line := range logger.lines {
+		if strings.Contains(line.Text, "sk-super-secret") {
+			t.Fatalf("streamed line leaked the secret: %q", line.Text)
+		}
+	}
+	if len(logger.lines) != 2 {
+		t.Fatalf("got %d streamed lines, want 2", len(logger.lines))
+	}
+	if !strings.Contains(logger.lines[0].Text, "***REDACTED***") {
+		t.Fatalf("expected the redaction placeholder in the streamed line, got %q", logger.lines[0].Text)
+	}
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/oklog/ulid/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const jobsBucket = "jobs"
+
+// Job is a unit of work submitted to the daemon: either a single Command
+// or a RuneConfig, run asynchronously and polled or streamed by id.
+type Job struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`   // "command" or "rune"
+	Status    string          `json:"status"` // "queued", "running", "succeeded", "failed", "cancelled"
+	Results   []CommandResult `json:"results,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// apiMetrics are the Prometheus counter and histogram exposed on /metrics.
+type apiMetrics struct {
+	commandsTotal *prometheus.CounterVec
+	jobDuration   prometheus.Histogram
+}
+
+// newAPIMetrics registers the daemon's counter and histogram against reg. Tests
+// pass a fresh prometheus.NewRegistry() so repeated apiServer construction
+// doesn't panic on duplicate registration against the global default
+// registry; cmdServe passes prometheus.DefaultRegisterer so /metrics keeps
+// serving them.
+func newAPIMetrics(reg prometheus.Registerer) *apiMetrics {
+	factory := promauto.With(reg)
+	return &apiMetrics{
+		commandsTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "platform_agent_commands_total",
+			Help: "Number of commands executed via the API, by outcome.",
+		}, []string{"status"}),
+		jobDuration: factory.NewHistogram(prometheus.HistogramOpts{
+			Name: "platform_agent_job_duration_seconds",
+			Help: "Duration of API-submitted jobs from queued to terminal state.",
+		}),
+	}
+}
+
+// apiServer holds the state shared by the HTTP handlers registered in
+// cmdServe: the job store, per-job cancel funcs and log broadcasters for
+// /stream, the bearer-token allow-lists, and metrics.
+type apiServer struct {
+	db      *bolt.DB
+	tokens  map[string]APIToken
+	metrics *apiMetrics
+
+	mu           sync.Mutex
+	cancels      map[string]context.CancelFunc
+	broadcasters map[string]*lineBroadcaster
+}
+
+// APIToken is a bearer token's identity: the rune names it is allowed to
+// submit ("*" allows any).
+type APIToken struct {
+	Name         string   `json:"name"`
+	AllowedRunes []string `json:"allowed_runes"`
+}
+
+func (t APIToken) allows(runeName string) bool {
+	for _, allowed := range t.AllowedRunes {
+		if allowed == "*" || allowed == runeName {
+			return true
+		}
+	}
+	return false
+}
+
+// lineBroadcaster fans a running job's Lines out to any number of
+// connected /stream clients.
+type lineBroadcaster struct {
+	mu   sync.Mutex
+	subs map[chan Line]struct{}
+}
+
+func newLineBroadcaster() *lineBroadcaster {
+	return &lineBroadcaster{subs: make(map[chan Line]struct{})}
+}
+
+func (b *lineBroadcaster) Write(line Line) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- line:
+		default: // slow subscriber, drop rather than block execution
+		}
+	}
+}
+
+func (b *lineBroadcaster) subscribe() chan Line {
+	ch := make(chan Line, 64)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *lineBroadcaster) unsubscribe(ch chan Line) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[ch]; !ok {
+		return
+	}
+	delete(b.subs, ch)
+	close(ch)
+}
+
+// closeAll disconnects every current subscriber, used once a job reaches
+// a terminal state so /stream handlers return instead of blocking forever.
+func (b *lineBroadcaster) closeAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		close(ch)
+		delete(b.subs, ch)
+	}
+}
+
+// cmdServe implements `platform_agent serve --listen :8080`, turning the
+// agent into a long-running control-plane component: job submission and
+// polling for the MLOps UI instead of a one-shot CLI invocation.
+func cmdServe(args []string) {
+	listen := ":8080"
+	dbPath := "platform_agent_jobs.db"
+	tokensFile := "api_tokens.json"
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--listen":
+			if i+1 < len(args) {
+				listen = args[i+1]
+				i++
+			}
+		case "--db":
+			if i+1 < len(args) {
+				dbPath = args[i+1]
+				i++
+			}
+		case "--tokens-file":
+			if i+1 < len(args) {
+				tokensFile = args[i+1]
+				i++
+			}
+		}
+	}
+
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		fmt.Printf("❌ Error opening job store: %v\n", err)
+		os.Exit(1)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(jobsBucket))
+		return err
+	}); err != nil {
+		fmt.Printf("❌ Error initializing job store: %v\n", err)
+		os.Exit(1)
+	}
+
+	tokens, err := loadAPITokens(tokensFile)
+	if err != nil {
+		fmt.Printf("❌ Error loading %s: %v\n", tokensFile, err)
+		os.Exit(1)
+	}
+
+	srv := &apiServer{
+		db:           db,
+		tokens:       tokens,
+		metrics:      newAPIMetrics(prometheus.DefaultRegisterer),
+		cancels:      make(map[string]context.CancelFunc),
+		broadcasters: make(map[string]*lineBroadcaster),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/commands", srv.withAuth(srv.handleSubmitCommand))
+	mux.HandleFunc("/v1/runes", srv.withAuth(srv.handleSubmitRune))
+	mux.HandleFunc("/v1/jobs/", srv.withAuth(srv.handleJob))
+	mux.Handle("/metrics", promhttp.Handler())
+
+	fmt.Printf("🔮 Platform Agent serving on %s (jobs: %s)\n", listen, dbPath)
+	if err := http.ListenAndServe(listen, mux); err != nil {
+		fmt.Printf("❌ Server error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// loadAPITokens reads the bearer-token allow-list file. A missing file
+// leaves the server with no valid tokens, so every request is rejected
+// rather than silently allowed.
+func loadAPITokens(path string) (map[string]APIToken, error) {
+	tokens := make(map[string]APIToken)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("invalid tokens file: %v", err)
+	}
+	return tokens, nil
+}
+
+// withAuth enforces the Authorization: Bearer <token> header against the
+// configured token allow-list before delegating to next.
+func (s *apiServer) withAuth(next func(http.ResponseWriter, *http.Request, APIToken)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		token, ok := strings.CutPrefix(auth, "Bearer ")
+		if !ok {
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		apiToken, ok := s.tokens[token]
+		if !ok {
+			http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+		next(w, r, apiToken)
+	}
+}
+
+func (s *apiServer) saveJob(job Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(jobsBucket)).Put([]byte(job.ID), data)
+	})
+}
+
+func (s *apiServer) loadJob(id string) (Job, bool, error) {
+	var job Job
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(jobsBucket)).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &job)
+	})
+	return job, found, err
+}
+
+func (s *apiServer) handleSubmitCommand(w http.ResponseWriter, r *http.Request, token APIToken) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		http.Error(w, fmt.Sprintf("invalid body: %v", err), http.StatusBadRequest)
+		return
+	}
+	var cmd Command
+	if err := cmd.UnmarshalJSON(raw); err != nil {
+		http.Error(w, fmt.Sprintf("invalid command: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !token.allows("*") {
+		http.Error(w, "token not authorized for single commands", http.StatusForbidden)
+		return
+	}
+
+	job := s.newJob("command")
+	go s.runCommandJob(job, cmd, token.Name)
+	writeJSON(w, http.StatusAccepted, map[string]string{"id": job.ID})
+}
+
+func (s *apiServer) handleSubmitRune(w http.ResponseWriter, r *http.Request, token APIToken) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var runeConfig RuneConfig
+	if err := json.NewDecoder(r.Body).Decode(&runeConfig); err != nil {
+		http.Error(w, fmt.Sprintf("invalid rune: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !token.allows(runeConfig.Name) {
+		http.Error(w, fmt.Sprintf("token not authorized for rune %q", runeConfig.Name), http.StatusForbidden)
+		return
+	}
+	if config.RequireSignedRunes {
+		http.Error(w, "rune signing is required; submit via \"platform_agent --rune\" with --trusted-keys instead of the API", http.StatusForbidden)
+		return
+	}
+
+	job := s.newJob("rune")
+	go s.runRuneJob(job, runeConfig, token.Name)
+	writeJSON(w, http.StatusAccepted, map[string]string{"id": job.ID})
+}
+
+func (s *apiServer) newJob(jobType string) Job {
+	job := Job{
+		ID:        ulid.Make().String(),
+		Type:      jobType,
+		Status:    "queued",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := s.saveJob(job); err != nil {
+		log.Printf("failed to persist job %s: %v", job.ID, err)
+	}
+	return job
+}
+
+// runCommandJob executes a single submitted command. The registered cancel
+// func is now the context executeCommand actually runs the child process
+// under, so /cancel interrupts a command already running, not just a
+// queued retry.
+func (s *apiServer) runCommandJob(job Job, cmd Command, caller string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.registerCancel(job.ID, cancel)
+	defer s.unregisterCancel(job.ID)
+	defer cancel()
+
+	start := time.Now()
+	job.Status = "running"
+	s.saveJob(job)
+
+	result := executeCommand(ctx, cmd, config.MaxTimeout, Target{}, nil, caller, s.broadcasterFor(job.ID))
+
+	job.Results = []CommandResult{result}
+	switch {
+	case ctx.Err() == context.Canceled:
+		job.Status = "cancelled"
+		s.metrics.commandsTotal.WithLabelValues("cancelled").Inc()
+	case result.Success:
+		job.Status = "succeeded"
+		s.metrics.commandsTotal.WithLabelValues("success").Inc()
+	default:
+		job.Status = "failed"
+		job.Error = result.Error
+		s.metrics.commandsTotal.WithLabelValues("failure").Inc()
+	}
+	job.UpdatedAt = time.Now()
+	s.metrics.jobDuration.Observe(time.Since(start).Seconds())
+	s.saveJob(job)
+	s.closeBroadcaster(job.ID)
+}
+
+// runRuneJob executes a submitted rune. See runCommandJob for how /cancel
+// now interrupts a running step instead of only a queued retry. A rune
+// submitted over the API has no manifest file to check a detached
+// signature against, so signer is always empty for these jobs.
+func (s *apiServer) runRuneJob(job Job, runeConfig RuneConfig, caller string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.registerCancel(job.ID, cancel)
+	defer s.unregisterCancel(job.ID)
+	defer cancel()
+
+	start := time.Now()
+	job.Status = "running"
+	s.saveJob(job)
+
+	results, err := executeRuneConfig(ctx, runeConfig, nil, nil, caller, "", s.broadcasterFor(job.ID))
+
+	job.Results = results
+	switch {
+	case ctx.Err() == context.Canceled:
+		job.Status = "cancelled"
+		s.metrics.commandsTotal.WithLabelValues("cancelled").Inc()
+	case err != nil:
+		job.Status = "failed"
+		job.Error = err.Error()
+		s.metrics.commandsTotal.WithLabelValues("failure").Inc()
+	default:
+		job.Status = "succeeded"
+		for _, r := range results {
+			if !r.Success {
+				job.Status = "failed"
+			}
+		}
+		s.metrics.commandsTotal.WithLabelValues(job.Status).Inc()
+	}
+	job.UpdatedAt = time.Now()
+	s.metrics.jobDuration.Observe(time.Since(start).Seconds())
+	s.saveJob(job)
+	s.closeBroadcaster(job.ID)
+}
+
+func (s *apiServer) registerCancel(id string, cancel context.CancelFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancels[id] = cancel
+}
+
+func (s *apiServer) unregisterCancel(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cancels, id)
+}
+
+func (s *apiServer) broadcasterFor(id string) *lineBroadcaster {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := newLineBroadcaster()
+	s.broadcasters[id] = b
+	return b
+}
+
+// closeBroadcaster disconnects any /stream clients and drops the
+// broadcaster once a job reaches a terminal state.
+func (s *apiServer) closeBroadcaster(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.broadcasters[id]; ok {
+		b.closeAll()
+		delete(s.broadcasters, id)
+	}
+}
+
+// handleJob dispatches GET /v1/jobs/{id}, GET /v1/jobs/{id}/stream, and
+// POST /v1/jobs/{id}/cancel.
+func (s *apiServer) handleJob(w http.ResponseWriter, r *http.Request, token APIToken) {
+	path := strings.TrimPrefix(r.URL.Path, "/v1/jobs/")
+	switch {
+	case strings.HasSuffix(path, "/stream"):
+		s.handleJobStream(w, r, strings.TrimSuffix(path, "/stream"))
+	case strings.HasSuffix(path, "/cancel"):
+		s.handleJobCancel(w, r, strings.TrimSuffix(path, "/cancel"))
+	default:
+		s.handleJobStatus(w, r, path)
+	}
+}
+
+func (s *apiServer) handleJobStatus(w http.ResponseWriter, r *http.Request, id string) {
+	job, found, err := s.loadJob(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *apiServer) handleJobCancel(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.mu.Lock()
+	cancel, ok := s.cancels[id]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "job not running", http.StatusNotFound)
+		return
+	}
+	cancel()
+	writeJSON(w, http.StatusOK, map[string]string{"status": "cancelling"})
+}
+
+// handleJobStream serves GET /v1/jobs/{id}/stream as Server-Sent Events,
+// forwarding each Line produced while the job is running.
+func (s *apiServer) handleJobStream(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	b, ok := s.broadcasters[id]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "job not found or already finished", http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := b.subscribe()
+	defer b.unsubscribe(ch)
+
+	for {
+		select {
+		case line, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(line)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
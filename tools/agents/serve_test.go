@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newTestAPIServer opens a throwaway job store and wires up an apiServer
+// against a fresh Prometheus registry, so tests can construct as many
+// servers as they like without panicking on duplicate metric registration
+// against the global default registry.
+func newTestAPIServer(t *testing.T, tokens map[string]APIToken) *apiServer {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "jobs.db"), 0600, nil)
+	if err != nil {
+		t.Fatalf("failed to open job store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(jobsBucket))
+		return err
+	}); err != nil {
+		t.Fatalf("failed to create jobs bucket: %v", err)
+	}
+
+	return &apiServer{
+		db:           db,
+		tokens:       tokens,
+		metrics:      newAPIMetrics(prometheus.NewRegistry()),
+		cancels:      make(map[string]context.CancelFunc),
+		broadcasters: make(map[string]*lineBroadcaster),
+	}
+}
+
+func TestWithAuth(t *testing.T) {
+	srv := newTestAPIServer(t, map[string]APIToken{
+		"good-token": {Name: "ci", AllowedRunes: []string{"*"}},
+	})
+
+	var gotToken APIToken
+	var called bool
+	next := func(w http.ResponseWriter, r *http.Request, token APIToken) {
+		called = true
+		gotToken = token
+		w.WriteHeader(http.StatusOK)
+	}
+
+	tests := []struct {
+		name       string
+		authHeader string
+		wantStatus int
+		wantCalled bool
+	}{
+		{name: "missing header", authHeader: "", wantStatus: http.StatusUnauthorized},
+		{name: "wrong scheme", authHeader: "Basic good-token", wantStatus: http.StatusUnauthorized},
+		{name: "unknown token", authHeader: "Bearer bad-token", wantStatus: http.StatusUnauthorized},
+		{name: "valid token", authHeader: "Bearer good-token", wantStatus: http.StatusOK, wantCalled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = false
+			gotToken = APIToken{}
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/commands", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			rec := httptest.NewRecorder()
+
+			srv.withAuth(next)(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if called != tt.wantCalled {
+				t.Fatalf("next called = %v, want %v", called, tt.wantCalled)
+			}
+			if tt.wantCalled && gotToken.Name != "ci" {
+				t.Fatalf("next received token %+v, want name %q", gotToken, "ci")
+			}
+		})
+	}
+}
+
+func TestHandleSubmitCommand_UnauthorizedToken(t *testing.T) {
+	srv := newTestAPIServer(t, nil)
+	token := APIToken{Name: "restricted", AllowedRunes: []string{"deploy"}}
+
+	body := strings.NewReader(`{"mode":"exec","exec":"echo","args":["hi"]}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/commands", body)
+	rec := httptest.NewRecorder()
+
+	srv.handleSubmitCommand(rec, req, token)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestHandleSubmitCommand_Accepted(t *testing.T) {
+	srv := newTestAPIServer(t, nil)
+	token := APIToken{Name: "ci", AllowedRunes: []string{"*"}}
+
+	body := strings.NewReader(`{"mode":"exec","exec":"true"}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/commands", body)
+	rec := httptest.NewRecorder()
+
+	srv.handleSubmitCommand(rec, req, token)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d, body: %s", rec.Code, http.StatusAccepted, rec.Body.String())
+	}
+	var resp map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp["id"] == "" {
+		t.Fatalf("expected a non-empty job id, got %+v", resp)
+	}
+}
+
+func TestHandleSubmitRune_RequireSignedRunesRejectsAPISubmission(t *testing.T) {
+	config.RequireSignedRunes = true
+	defer func() { config.RequireSignedRunes = false }()
+
+	srv := newTestAPIServer(t, nil)
+	token := APIToken{Name: "ci", AllowedRunes: []string{"*"}}
+
+	body := strings.NewReader(`{"name":"deploy","commands":[]}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/runes", body)
+	rec := httptest.NewRecorder()
+
+	srv.handleSubmitRune(rec, req, token)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d, body: %s", rec.Code, http.StatusForbidden, rec.Body.String())
+	}
+}
+
+func TestHandleJobCancel(t *testing.T) {
+	srv := newTestAPIServer(t, nil)
+
+	t.Run("unknown job", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/jobs/missing/cancel", nil)
+		rec := httptest.NewRecorder()
+
+		srv.handleJobCancel(rec, req, "missing")
+
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("running job", func(t *testing.T) {
+		var mu sync.Mutex
+		cancelled := false
+		srv.registerCancel("job-1", func() {
+			mu.Lock()
+			defer mu.Unlock()
+			cancelled = true
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/jobs/job-1/cancel", nil)
+		rec := httptest.NewRecorder()
+
+		srv.handleJobCancel(rec, req, "job-1")
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d, body: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !cancelled {
+			t.Fatalf("expected the registered cancel func to have been called")
+		}
+	})
+
+	t.Run("wrong method", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/jobs/job-1/cancel", nil)
+		rec := httptest.NewRecorder()
+
+		srv.handleJobCancel(rec, req, "job-1")
+
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+		}
+	})
+}
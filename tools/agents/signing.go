@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TrustedKey is one accepted signer's public key, loaded from a
+// --trusted-keys directory entry named "<id>.pub" holding the
+// base64-encoded ed25519 public key.
+type TrustedKey struct {
+	ID        string
+	PublicKey ed25519.PublicKey
+}
+
+// loadTrustedKeys reads every "*.pub" file in dir into a map keyed by
+// signer id (the filename without the .pub suffix).
+func loadTrustedKeys(dir string) (map[string]TrustedKey, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trusted-keys directory: %v", err)
+	}
+
+	keys := make(map[string]TrustedKey)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pub") {
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), ".pub")
+		data, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key %q: %v", entry.Name(), err)
+		}
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key encoding in %q: %v", entry.Name(), err)
+		}
+		if len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("key %q: expected %d-byte ed25519 key, got %d", entry.Name(), ed25519.PublicKeySize, len(raw))
+		}
+		keys[id] = TrustedKey{ID: id, PublicKey: ed25519.PublicKey(raw)}
+	}
+	return keys, nil
+}
+
+// verifyRuneSignature checks a minisign-style detached signature sidecar
+// (three lines: an untrusted comment, the base64 signature, and a
+// "signer: <id>" line identifying which trusted key to verify against)
+// over manifest, and returns the signer id on success.
+func verifyRuneSignature(manifest []byte, sigPath string, trustedKeys map[string]TrustedKey) (string, error) {
+	data, err := ioutil.ReadFile(sigPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read signature file: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) < 3 {
+		return "", fmt.Errorf("malformed signature file: expected comment, signature, and signer lines")
+	}
+
+	sigBytes, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil {
+		return "", fmt.Errorf("invalid signature encoding: %v", err)
+	}
+
+	signerID, ok := strings.CutPrefix(strings.TrimSpace(lines[2]), "signer: ")
+	if !ok {
+		return "", fmt.Errorf("malformed signature file: missing \"signer: <id>\" line")
+	}
+
+	key, ok := trustedKeys[signerID]
+	if !ok {
+		return "", fmt.Errorf("unknown signer: %s", signerID)
+	}
+	if !ed25519.Verify(key.PublicKey, manifest, sigBytes) {
+		return "", fmt.Errorf("signature verification failed for signer %q", signerID)
+	}
+	return signerID, nil
+}
+
+// verifyRuneFile checks runeFile's manifest bytes against its detached
+// signature sidecar (runeFile + ".sig") using the keys in trustedKeysDir,
+// returning the signer identity. When trustedKeysDir is empty or no
+// sidecar is present, verification is skipped and an empty signer is
+// returned; config.RequireSignedRunes decides whether that's acceptable.
+func verifyRuneFile(manifest []byte, runeFile, trustedKeysDir string) (string, error) {
+	if trustedKeysDir == "" {
+		if config.RequireSignedRunes {
+			return "", fmt.Errorf("rune signing is required but --trusted-keys was not set")
+		}
+		return "", nil
+	}
+
+	sigPath := runeFile + ".sig"
+	if _, err := os.Stat(sigPath); err != nil {
+		if config.RequireSignedRunes {
+			return "", fmt.Errorf("rune %q is not signed (missing %s)", runeFile, sigPath)
+		}
+		return "", nil
+	}
+
+	trustedKeys, err := loadTrustedKeys(trustedKeysDir)
+	if err != nil {
+		return "", err
+	}
+	signer, err := verifyRuneSignature(manifest, sigPath, trustedKeys)
+	if err != nil {
+		return "", fmt.Errorf("rune %q: %v", runeFile, err)
+	}
+	return signer, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestKey generates an ed25519 keypair, writes its public half to
+// "<dir>/<id>.pub" in the format loadTrustedKeys expects, and returns the
+// private key for signing.
+func writeTestKey(t *testing.T, dir, id string) ed25519.PrivateKey {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate test key: %v", err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(pub)
+	if err := os.WriteFile(filepath.Join(dir, id+".pub"), []byte(encoded+"\n"), 0644); err != nil {
+		t.Fatalf("failed to write test key: %v", err)
+	}
+	return priv
+}
+
+// writeTestSignature signs manifest with priv and writes the 3-line
+// minisign-style sidecar verifyRuneSignature expects at sigPath.
+func writeTestSignature(t *testing.T, sigPath, signerID string, priv ed25519.PrivateKey, manifest []byte) {
+	t.Helper()
+	sig := ed25519.Sign(priv, manifest)
+	content := "untrusted comment: signed by platform_agent\n" +
+		base64.StdEncoding.EncodeToString(sig) + "\n" +
+		"signer: " + signerID + "\n"
+	if err := os.WriteFile(sigPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test signature: %v", err)
+	}
+}
+
+func TestVerifyRuneSignature(t *testing.T) {
+	dir := t.TempDir()
+	priv := writeTestKey(t, dir, "ops-team")
+	manifest := []byte(`{"name":"deploy"}`)
+	sigPath := filepath.Join(dir, "rune.json.sig")
+	writeTestSignature(t, sigPath, "ops-team", priv, manifest)
+
+	trustedKeys, err := loadTrustedKeys(dir)
+	if err != nil {
+		t.Fatalf("loadTrustedKeys: %v", err)
+	}
+
+	signer, err := verifyRuneSignature(manifest, sigPath, trustedKeys)
+	if err != nil {
+		t.Fatalf("verifyRuneSignature: %v", err)
+	}
+	if signer != "ops-team" {
+		t.Fatalf("got signer %q, want \"ops-team\"", signer)
+	}
+}
+
+func TestVerifyRuneSignature_TamperedManifestFails(t *testing.T) {
+	dir := t.TempDir()
+	priv := writeTestKey(t, dir, "ops-team")
+	sigPath := filepath.Join(dir, "rune.json.sig")
+	writeTestSignature(t, sigPath, "ops-team", priv, []byte(`{"name":"deploy"}`))
+
+	trustedKeys, err := loadTrustedKeys(dir)
+	if err != nil {
+		t.Fatalf("loadTrustedKeys: %v", err)
+	}
+
+	if _, err := verifyRuneSignature([]byte(`{"name":"tampered"}`), sigPath, trustedKeys); err == nil {
+		t.Fatalf("expected signature verification to fail for a tampered manifest")
+	}
+}
+
+func TestVerifyRuneSignature_UnknownSignerFails(t *testing.T) {
+	dir := t.TempDir()
+	priv := writeTestKey(t, dir, "ops-team")
+	manifest := []byte(`{"name":"deploy"}`)
+	sigPath := filepath.Join(dir, "rune.json.sig")
+	writeTestSignature(t, sigPath, "someone-else", priv, manifest)
+
+	trustedKeys, err := loadTrustedKeys(dir)
+	if err != nil {
+		t.Fatalf("loadTrustedKeys: %v", err)
+	}
+
+	if _, err := verifyRuneSignature(manifest, sigPath, trustedKeys); err == nil {
+		t.Fatalf("expected verification to fail for a signer with no trusted key")
+	}
+}
+
+func TestVerifyRuneFile_RequiredButUnsigned(t *testing.T) {
+	config.RequireSignedRunes = true
+	defer func() { config.RequireSignedRunes = false }()
+
+	dir := t.TempDir()
+	runeFile := filepath.Join(dir, "rune.json")
+	if err := os.WriteFile(runeFile, []byte(`{"name":"deploy"}`), 0644); err != nil {
+		t.Fatalf("failed to write rune file: %v", err)
+	}
+	keysDir := t.TempDir()
+	writeTestKey(t, keysDir, "ops-team")
+
+	if _, err := verifyRuneFile([]byte(`{"name":"deploy"}`), runeFile, keysDir); err == nil {
+		t.Fatalf("expected an error for a required-but-missing signature")
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// SecretSpec describes where to load one secret from. The value is read
+// once, injected only into a child process's environment, and never
+// written to a rune's own command strings, logs, or CommandResult.
+type SecretSpec struct {
+	Name   string `json:"name"`
+	File   string `json:"file,omitempty"`
+	EnvVar string `json:"env,omitempty"`
+}
+
+// varPattern matches ${VAR} and ${VAR:-default}.
+var varPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandString performs envsubst-style expansion of ${VAR} and
+// ${VAR:-default} references against vars, falling back to the process
+// environment, and fails fast if a reference has neither a value nor a
+// default rather than sending "${...}" through to the shell unexpanded.
+func expandString(s string, vars map[string]string) (string, error) {
+	var firstErr error
+	expanded := varPattern.ReplaceAllStringFunc(s, func(match string) string {
+		groups := varPattern.FindStringSubmatch(match)
+		name, hasDefault, def := groups[1], groups[2] != "", groups[3]
+
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		if v, ok := os.LookupEnv(name); ok {
+			return v
+		}
+		if hasDefault {
+			return def
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("unresolved variable reference: ${%s}", name)
+		}
+		return match
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return expanded, nil
+}
+
+// expandCommand runs a Command's exec/args/shell fields through
+// expandString, returning a new Command with all references resolved.
+func expandCommand(cmd Command, vars map[string]string) (Command, error) {
+	out := cmd
+	var err error
+	if out.Exec, err = expandString(cmd.Exec, vars); err != nil {
+		return Command{}, err
+	}
+	out.Args = make([]string, len(cmd.Args))
+	for i, a := range cmd.Args {
+		if out.Args[i], err = expandString(a, vars); err != nil {
+			return Command{}, err
+		}
+	}
+	if out.Shell, err = expandString(cmd.Shell, vars); err != nil {
+		return Command{}, err
+	}
+	return out, nil
+}
+
+// mergeVars combines variable sources by precedence, later maps in the
+// argument list winning: rune vars, then --vars-file, then CLI --set.
+func mergeVars(sources ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, src := range sources {
+		for k, v := range src {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// parseVarsFile loads a flat string-keyed map from a JSON object, or from
+// a minimal "key: value" per line YAML subset (no nesting or lists).
+func parseVarsFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vars file: %v", err)
+	}
+
+	vars := make(map[string]string)
+	if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid vars file line: %q", line)
+			}
+			vars[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+		return vars, nil
+	}
+
+	if err := json.Unmarshal(data, &vars); err != nil {
+		return nil, fmt.Errorf("failed to parse vars file as JSON: %v", err)
+	}
+	return vars, nil
+}
+
+// loadSecrets resolves each SecretSpec to its value, from a file or an
+// environment variable, for injection into a child process's environment.
+func loadSecrets(specs []SecretSpec) (map[string]string, error) {
+	secrets := make(map[string]string)
+	for _, spec := range specs {
+		switch {
+		case spec.File != "":
+			data, err := ioutil.ReadFile(spec.File)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load secret %q: %v", spec.Name, err)
+			}
+			secrets[spec.Name] = strings.TrimSpace(string(data))
+		case spec.EnvVar != "":
+			v, ok := os.LookupEnv(spec.EnvVar)
+			if !ok {
+				return nil, fmt.Errorf("secret %q: environment variable %s not set", spec.Name, spec.EnvVar)
+			}
+			secrets[spec.Name] = v
+		default:
+			return nil, fmt.Errorf("secret %q must set either \"file\" or \"env\"", spec.Name)
+		}
+	}
+	return secrets, nil
+}
+
+// redactSecrets replaces every occurrence of a loaded secret value with a
+// placeholder before a string is persisted to CommandResult or a log.
+func redactSecrets(s string, secrets map[string]string) string {
+	for _, v := range secrets {
+		if v == "" {
+			continue
+		}
+		s = strings.ReplaceAll(s, v, "***REDACTED***")
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+func TestExpandString(t *testing.T) {
+	t.Setenv("PLATFORM_AGENT_TEST_VAR", "from-env")
+
+	cases := []struct {
+		name    string
+		s       string
+		vars    map[string]string
+		want    string
+		wantErr bool
+	}{
+		{name: "no references", s: "kubectl get pods", want: "kubectl get pods"},
+		{name: "resolved from vars", s: "${NAMESPACE}", vars: map[string]string{"NAMESPACE": "prod"}, want: "prod"},
+		{name: "vars take precedence over env", s: "${PLATFORM_AGENT_TEST_VAR}", vars: map[string]string{"PLATFORM_AGENT_TEST_VAR": "from-vars"}, want: "from-vars"},
+		{name: "falls back to env", s: "${PLATFORM_AGENT_TEST_VAR}", want: "from-env"},
+		{name: "default used when unresolved", s: "${MISSING:-fallback}", want: "fallback"},
+		{name: "value wins over default", s: "${NAMESPACE:-fallback}", vars: map[string]string{"NAMESPACE": "prod"}, want: "prod"},
+		{name: "unresolved fails fast", s: "${MISSING}", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := expandString(tc.s, tc.vars)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExpandCommand(t *testing.T) {
+	cmd := Command{Mode: "exec", Exec: "kubectl", Args: []string{"apply", "-f", "${MANIFEST}", "-n", "${NAMESPACE:-default}"}}
+	expanded, err := expandCommand(cmd, map[string]string{"MANIFEST": "deployment.yaml"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"apply", "-f", "deployment.yaml", "-n", "default"}
+	if len(expanded.Args) != len(want) {
+		t.Fatalf("got args %v, want %v", expanded.Args, want)
+	}
+	for i, a := range want {
+		if expanded.Args[i] != a {
+			t.Fatalf("arg %d: got %q, want %q", i, expanded.Args[i], a)
+		}
+	}
+}
+
+func TestExpandCommand_UnresolvedFailsFast(t *testing.T) {
+	cmd := Command{Mode: "shell", Shell: "echo ${MISSING}"}
+	if _, err := expandCommand(cmd, nil); err == nil {
+		t.Fatalf("expected an error for an unresolved shell reference")
+	}
+}
+
+func TestMergeVars(t *testing.T) {
+	runeVars := map[string]string{"A": "rune", "B": "rune"}
+	fileVars := map[string]string{"B": "file", "C": "file"}
+	cliVars := map[string]string{"C": "cli"}
+
+	merged := mergeVars(runeVars, fileVars, cliVars)
+	want := map[string]string{"A": "rune", "B": "file", "C": "cli"}
+	for k, v := range want {
+		if merged[k] != v {
+			t.Fatalf("key %q: got %q, want %q", k, merged[k], v)
+		}
+	}
+}